@@ -0,0 +1,26 @@
+// SPDX-License-Identifier: MIT
+// SPDX-FileCopyrightText: © 2017 LabStack and Echo contributors
+
+package jaegertracing
+
+import "github.com/labstack/echo/v4"
+
+// SetBaggage attaches a baggage item to the span on c's request, so it propagates to every
+// downstream span on the same trace - including ones created by the traced HTTP/gRPC clients
+// in this package. Only a span started by TraceWithConfig carries baggage this way; calling
+// this outside of a traced request is a no-op.
+func SetBaggage(c echo.Context, key, value string) {
+	if sp := SpanFromEchoContext(c); sp != nil {
+		sp.SetBaggageItem(key, value)
+	}
+}
+
+// GetBaggage returns the value of a baggage item previously set with SetBaggage, by
+// TraceConfig.BaggageExtractor, or by an upstream service on the same trace. Returns "" if
+// there is no span, or no such item.
+func GetBaggage(c echo.Context, key string) string {
+	if sp := SpanFromEchoContext(c); sp != nil {
+		return sp.BaggageItem(key)
+	}
+	return ""
+}
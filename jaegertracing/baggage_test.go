@@ -0,0 +1,98 @@
+// SPDX-License-Identifier: MIT
+// SPDX-FileCopyrightText: © 2017 LabStack and Echo contributors
+
+package jaegertracing
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/mocktracer"
+)
+
+func TestBaggage_RoundTrip(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	mw := TraceWithConfig(TraceConfig{Tracer: mocktracer.New()})
+	err := mw(func(c echo.Context) error {
+		SetBaggage(c, "tenant_id", "acme")
+		if got := GetBaggage(c, "tenant_id"); got != "acme" {
+			t.Errorf("GetBaggage() = %q, want %q", got, "acme")
+		}
+		return nil
+	})(c)
+	if err != nil {
+		t.Fatalf("handler returned error: %v", err)
+	}
+}
+
+func TestBaggageExtractor_AllowList(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	mw := TraceWithConfig(TraceConfig{
+		Tracer: mocktracer.New(),
+		BaggageExtractor: func(c echo.Context) map[string]string {
+			return map[string]string{"tenant_id": "acme", "x-injected": "attacker"}
+		},
+		BaggageAllowList: []string{"tenant_id"},
+	})
+	err := mw(func(c echo.Context) error {
+		if got := GetBaggage(c, "tenant_id"); got != "acme" {
+			t.Errorf("GetBaggage(tenant_id) = %q, want %q", got, "acme")
+		}
+		if got := GetBaggage(c, "x-injected"); got != "" {
+			t.Errorf("GetBaggage(x-injected) = %q, want empty (not allow-listed)", got)
+		}
+		return nil
+	})(c)
+	if err != nil {
+		t.Fatalf("handler returned error: %v", err)
+	}
+}
+
+// TestBaggageAllowList_FiltersIncomingBaggage covers the actual threat BaggageAllowList
+// defends against: baggage an upstream service (or a spoofing client) injects via the
+// Tracer's own propagation headers, not just values from BaggageExtractor.
+func TestBaggageAllowList_FiltersIncomingBaggage(t *testing.T) {
+	tracer := mocktracer.New()
+
+	upstream := tracer.StartSpan("upstream")
+	upstream.SetBaggageItem("tenant_id", "acme")
+	upstream.SetBaggageItem("x-injected", "attacker")
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	if err := tracer.Inject(upstream.Context(), opentracing.HTTPHeaders, opentracing.HTTPHeadersCarrier(req.Header)); err != nil {
+		t.Fatalf("failed to inject upstream baggage: %v", err)
+	}
+	upstream.Finish()
+
+	e := echo.New()
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	mw := TraceWithConfig(TraceConfig{
+		Tracer:           tracer,
+		BaggageAllowList: []string{"tenant_id"},
+	})
+	err := mw(func(c echo.Context) error {
+		if got := GetBaggage(c, "tenant_id"); got != "acme" {
+			t.Errorf("GetBaggage(tenant_id) = %q, want %q", got, "acme")
+		}
+		if got := GetBaggage(c, "x-injected"); got != "" {
+			t.Errorf("GetBaggage(x-injected) = %q, want empty (not allow-listed, came from upstream headers)", got)
+		}
+		return nil
+	})(c)
+	if err != nil {
+		t.Fatalf("handler returned error: %v", err)
+	}
+}
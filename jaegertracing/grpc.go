@@ -0,0 +1,191 @@
+// SPDX-License-Identifier: MIT
+// SPDX-FileCopyrightText: © 2017 LabStack and Echo contributors
+
+package jaegertracing
+
+import (
+	"context"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// metadataTextMapCarrier adapts grpc metadata.MD to opentracing's TextMapWriter/TextMapReader
+// so a span context can be injected into / extracted from outgoing and incoming grpc metadata.
+type metadataTextMapCarrier struct {
+	metadata.MD
+}
+
+func (c metadataTextMapCarrier) Set(key, val string) {
+	c.MD.Set(key, val)
+}
+
+func (c metadataTextMapCarrier) ForeachKey(handler func(key, val string) error) error {
+	for k, vs := range c.MD {
+		for _, v := range vs {
+			if err := handler(k, v); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// UnaryClientInterceptor returns a grpc.UnaryClientInterceptor that starts a client span as a
+// child of the span on ctx (e.g. the one TraceWithConfig placed on the Echo request context,
+// reachable via req.Context() or SpanFromEchoContext), injects it into the outgoing grpc
+// metadata, and tags grpc.method/grpc.service/grpc.code on completion.
+func UnaryClientInterceptor(tracer opentracing.Tracer) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		sp, ctx := startGRPCClientSpan(ctx, tracer, method)
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		finishGRPCSpan(sp, err)
+		return err
+	}
+}
+
+// StreamClientInterceptor returns a grpc.StreamClientInterceptor with the same tracing
+// behaviour as UnaryClientInterceptor, finishing the span once the stream is closed.
+func StreamClientInterceptor(tracer opentracing.Tracer) grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		sp, ctx := startGRPCClientSpan(ctx, tracer, method)
+		cs, err := streamer(ctx, desc, cc, method, opts...)
+		if err != nil {
+			finishGRPCSpan(sp, err)
+			return cs, err
+		}
+		return &tracedClientStream{ClientStream: cs, span: sp}, nil
+	}
+}
+
+// UnaryServerInterceptor returns a grpc.UnaryServerInterceptor that extracts the span context
+// from incoming grpc metadata (as injected by UnaryClientInterceptor, or any other tracer
+// using the same propagation format) and starts a server span as its child.
+func UnaryServerInterceptor(tracer opentracing.Tracer) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		sp, ctx := startGRPCServerSpan(ctx, tracer, info.FullMethod)
+		resp, err := handler(ctx, req)
+		finishGRPCSpan(sp, err)
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor returns a grpc.StreamServerInterceptor with the same tracing
+// behaviour as UnaryServerInterceptor.
+func StreamServerInterceptor(tracer opentracing.Tracer) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		sp, ctx := startGRPCServerSpan(ss.Context(), tracer, info.FullMethod)
+		err := handler(srv, &tracedServerStream{ServerStream: ss, ctx: ctx})
+		finishGRPCSpan(sp, err)
+		return err
+	}
+}
+
+func startGRPCClientSpan(ctx context.Context, tracer opentracing.Tracer, method string) (opentracing.Span, context.Context) {
+	var opts []opentracing.StartSpanOption
+	if parent := opentracing.SpanFromContext(ctx); parent != nil {
+		opts = append(opts, opentracing.ChildOf(parent.Context()))
+	}
+	sp := tracer.StartSpan(method, opts...)
+	ext.SpanKindRPCClient.Set(sp)
+	ext.Component.Set(sp, defaultComponentName)
+	sp.SetTag("grpc.method", method)
+	sp.SetTag("grpc.service", grpcServiceFromMethod(method))
+
+	md, ok := metadata.FromOutgoingContext(ctx)
+	if ok {
+		md = md.Copy()
+	} else {
+		md = metadata.MD{}
+	}
+	if err := tracer.Inject(sp.Context(), opentracing.TextMap, metadataTextMapCarrier{md}); err != nil {
+		sp.LogKV("error.message", "failed to inject trace headers: "+err.Error())
+	}
+
+	ctx = metadata.NewOutgoingContext(ctx, md)
+	ctx = opentracing.ContextWithSpan(ctx, sp)
+	return sp, ctx
+}
+
+func startGRPCServerSpan(ctx context.Context, tracer opentracing.Tracer, method string) (opentracing.Span, context.Context) {
+	var spanCtx opentracing.SpanContext
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		spanCtx, _ = tracer.Extract(opentracing.TextMap, metadataTextMapCarrier{md})
+	}
+
+	var sp opentracing.Span
+	if spanCtx != nil {
+		sp = tracer.StartSpan(method, ext.RPCServerOption(spanCtx))
+	} else {
+		sp = tracer.StartSpan(method)
+	}
+	ext.Component.Set(sp, defaultComponentName)
+	sp.SetTag("grpc.method", method)
+	sp.SetTag("grpc.service", grpcServiceFromMethod(method))
+
+	return sp, opentracing.ContextWithSpan(ctx, sp)
+}
+
+// grpcServiceFromMethod extracts the "pkg.Service" portion of a full grpc method name of the
+// form "/pkg.Service/Method". Returns the input unchanged if it isn't in that form.
+func grpcServiceFromMethod(method string) string {
+	method = strings.TrimPrefix(method, "/")
+	if idx := strings.IndexByte(method, '/'); idx >= 0 {
+		return method[:idx]
+	}
+	return method
+}
+
+func finishGRPCSpan(sp opentracing.Span, err error) {
+	defer sp.Finish()
+	sp.SetTag("grpc.code", status.Code(err).String())
+	if err != nil {
+		logError(sp, err)
+	}
+}
+
+// tracedClientStream finishes its span, at most once, when the stream is fully consumed or
+// errors out on either the send or the receive side.
+type tracedClientStream struct {
+	grpc.ClientStream
+	span       opentracing.Span
+	finishOnce sync.Once
+}
+
+func (s *tracedClientStream) finish(err error) {
+	s.finishOnce.Do(func() { finishGRPCSpan(s.span, err) })
+}
+
+func (s *tracedClientStream) SendMsg(m interface{}) error {
+	err := s.ClientStream.SendMsg(m)
+	if err != nil {
+		s.finish(err)
+	}
+	return err
+}
+
+func (s *tracedClientStream) RecvMsg(m interface{}) error {
+	err := s.ClientStream.RecvMsg(m)
+	if err == io.EOF {
+		s.finish(nil)
+	} else if err != nil {
+		s.finish(err)
+	}
+	return err
+}
+
+// tracedServerStream overrides Context so handlers observe the span-carrying context.
+type tracedServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *tracedServerStream) Context() context.Context {
+	return s.ctx
+}
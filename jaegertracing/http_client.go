@@ -0,0 +1,219 @@
+// SPDX-License-Identifier: MIT
+// SPDX-FileCopyrightText: © 2017 LabStack and Echo contributors
+
+package jaegertracing
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+)
+
+type (
+	// HTTPClientConfig defines the config for TracedTransport.
+	HTTPClientConfig struct {
+		// Tracer instance which should be got before
+		Tracer opentracing.Tracer
+
+		// ComponentName used for describing the tracing component name
+		ComponentName string
+
+		// RedactQuery replaces query string values with "REDACTED" (keeping the keys) in the
+		// http.url tag, so secrets/PII passed as query params never reach the tracer.
+		RedactQuery bool
+
+		// add req body & resp body to tracing tags
+		IsBodyDump bool
+
+		// prevent logging long http request bodies
+		LimitHTTPBody bool
+
+		// http body limit size (in bytes)
+		LimitSize int
+
+		// OperationNameFunc composes the client span operation name from the outgoing
+		// request. Can be used to override default naming.
+		OperationNameFunc func(req *http.Request) string
+
+		// MaxRetries is the number of times a request is retried after a failed
+		// RoundTrip (a transport error, or a 5xx response). Zero disables retries.
+		MaxRetries int
+
+		// RetryBackoff returns how long to wait before retry attempt n (1-based).
+		// Defaults to exponential backoff starting at 100ms.
+		RetryBackoff func(attempt int) time.Duration
+	}
+
+	// TracedTransport is an http.RoundTripper that wraps another RoundTripper
+	// (Base, defaulting to http.DefaultTransport), starting a client span for
+	// every request it carries out and injecting it into the outgoing headers.
+	TracedTransport struct {
+		Base   http.RoundTripper
+		config HTTPClientConfig
+	}
+)
+
+// DefaultHTTPClientConfig is the default TracedTransport config.
+var DefaultHTTPClientConfig = HTTPClientConfig{
+	ComponentName:     defaultComponentName,
+	LimitHTTPBody:     true,
+	LimitSize:         60_000,
+	OperationNameFunc: defaultClientOperationName,
+	MaxRetries:        0,
+	RetryBackoff:      defaultRetryBackoff,
+}
+
+// NewTracedTransport returns a TracedTransport wrapping base (http.DefaultTransport if nil).
+func NewTracedTransport(config HTTPClientConfig, base http.RoundTripper) *TracedTransport {
+	if config.Tracer == nil {
+		panic("echo: traced transport requires opentracing tracer")
+	}
+	if config.ComponentName == "" {
+		config.ComponentName = defaultComponentName
+	}
+	if config.OperationNameFunc == nil {
+		config.OperationNameFunc = defaultClientOperationName
+	}
+	if config.RetryBackoff == nil {
+		config.RetryBackoff = defaultRetryBackoff
+	}
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &TracedTransport{Base: base, config: config}
+}
+
+// NewHTTPClient returns an *http.Client whose RoundTripper is a TracedTransport wrapping
+// base.Transport (or http.DefaultTransport if base is nil).
+func NewHTTPClient(config HTTPClientConfig, base *http.Client) *http.Client {
+	var transport http.RoundTripper
+	timeout := 30 * time.Second
+	if base != nil {
+		transport = base.Transport
+		timeout = base.Timeout
+	}
+	return &http.Client{
+		Transport: NewTracedTransport(config, transport),
+		Timeout:   timeout,
+	}
+}
+
+// RoundTrip implements http.RoundTripper. It starts a client span as a child of the span
+// found on req.Context() (as set by TraceWithConfig/SpanFromEchoContext), injects it into
+// the outgoing request headers, retries on transport errors/5xx per config.MaxRetries, and
+// finishes the span with the final outcome.
+func (t *TracedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	parentSpan := opentracing.SpanFromContext(req.Context())
+	var spanOpts []opentracing.StartSpanOption
+	if parentSpan != nil {
+		spanOpts = append(spanOpts, opentracing.ChildOf(parentSpan.Context()))
+	}
+
+	sp := t.config.Tracer.StartSpan(t.config.OperationNameFunc(req), spanOpts...)
+	defer sp.Finish()
+
+	ext.SpanKindRPCClient.Set(sp)
+	ext.Component.Set(sp, t.config.ComponentName)
+	ext.HTTPMethod.Set(sp, req.Method)
+	ext.HTTPUrl.Set(sp, t.redactedURL(req.URL))
+	ext.PeerService.Set(sp, req.URL.Hostname())
+
+	// http.RoundTripper implementations must not modify the request (net/http and otelhttp
+	// follow the same rule), so inject headers and swap Body on a clone rather than req
+	// itself - Clone deep-copies Header, so the caller's original headers are untouched.
+	outReq := req.Clone(req.Context())
+
+	if err := t.config.Tracer.Inject(sp.Context(), opentracing.HTTPHeaders, opentracing.HTTPHeadersCarrier(outReq.Header)); err != nil {
+		sp.LogKV("error.message", "failed to inject trace headers: "+err.Error())
+	}
+
+	// Buffer the request body whenever we might need to replay it - for logging
+	// (IsBodyDump) or for a retry - not just for IsBodyDump, otherwise a retried
+	// POST/PUT would resend with an empty body since the first attempt already
+	// consumed it.
+	var reqBody []byte
+	if outReq.Body != nil && (t.config.IsBodyDump || t.config.MaxRetries > 0) {
+		reqBody, _ = io.ReadAll(outReq.Body)
+		outReq.Body = io.NopCloser(bytes.NewReader(reqBody))
+	}
+	if t.config.IsBodyDump && outReq.Body != nil {
+		body := string(reqBody)
+		if t.config.LimitHTTPBody {
+			body = limitString(body, t.config.LimitSize)
+		}
+		sp.LogKV("http.req.body", body)
+	}
+
+	var resp *http.Response
+	var err error
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 {
+			if resp != nil {
+				_, _ = io.Copy(io.Discard, resp.Body)
+				resp.Body.Close()
+			}
+			if reqBody != nil {
+				outReq.Body = io.NopCloser(bytes.NewReader(reqBody))
+			}
+			sp.LogKV("event", "retry", "attempt", attempt)
+			time.Sleep(t.config.RetryBackoff(attempt))
+		}
+
+		resp, err = t.Base.RoundTrip(outReq)
+		if err == nil && (resp.StatusCode < 500 || attempt >= t.config.MaxRetries) {
+			break
+		}
+		if err != nil && attempt >= t.config.MaxRetries {
+			break
+		}
+	}
+
+	if err != nil {
+		logError(sp, err)
+		return resp, err
+	}
+
+	ext.HTTPStatusCode.Set(sp, uint16(resp.StatusCode))
+	if resp.StatusCode >= 500 {
+		sp.SetTag("error", true)
+	}
+
+	if t.config.IsBodyDump {
+		respBody, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		resp.Body = io.NopCloser(bytes.NewReader(respBody))
+		body := string(respBody)
+		if t.config.LimitHTTPBody {
+			body = limitString(body, t.config.LimitSize)
+		}
+		sp.LogKV("http.resp.body", body)
+	}
+
+	return resp, nil
+}
+
+func (t *TracedTransport) redactedURL(u *url.URL) string {
+	if !t.config.RedactQuery || u.RawQuery == "" {
+		return u.String()
+	}
+	redacted := *u
+	q := redacted.Query()
+	for k := range q {
+		q[k] = []string{"REDACTED"}
+	}
+	redacted.RawQuery = q.Encode()
+	return redacted.String()
+}
+
+func defaultClientOperationName(req *http.Request) string {
+	return "HTTP Client " + req.Method + " " + req.URL.Hostname()
+}
+
+func defaultRetryBackoff(attempt int) time.Duration {
+	return 100 * time.Millisecond << (attempt - 1) // 100ms, 200ms, 400ms, ...
+}
@@ -33,6 +33,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	mathrand "math/rand"
 	"net/http"
 	"reflect"
 	"runtime"
@@ -71,6 +72,31 @@ type (
 
 		// OperationNameFunc composes operation name based on context. Can be used to override default naming
 		OperationNameFunc func(c echo.Context) string
+
+		// SamplingDecisionFunc, when set, is invoked for every request before the span is
+		// started to decide whether it should be sampled. Returning sample=false drops the
+		// span (sampling.priority=0), sample=true forces it to be kept (sampling.priority=1
+		// unless priority is set higher). Takes precedence over RouteSampling.
+		SamplingDecisionFunc func(c echo.Context) (sample bool, priority uint16)
+
+		// RouteSampling maps an Echo route path (c.Path(), e.g. "/api/checkout") to a
+		// head-based sampling rate between 0 and 1. It is only consulted when
+		// SamplingDecisionFunc is nil, and only for routes present in the map - routes not
+		// listed fall back to the Tracer's own sampler.
+		RouteSampling map[string]float64
+
+		// BaggageExtractor, when set, is invoked once at span start to seed baggage items
+		// from the incoming request (headers, JWT claims, cookies, ...). Keys it returns are
+		// still subject to BaggageAllowList.
+		BaggageExtractor func(c echo.Context) map[string]string
+
+		// BaggageAllowList restricts which baggage keys are honored on the span: both ones
+		// returned by BaggageExtractor and ones an upstream service carried in via the
+		// Tracer's own propagation (extracted via config.Tracer.Extract in TraceWithConfig) are cleared if
+		// not listed here. Empty means all keys are allowed. Set this when baggage originates
+		// from untrusted clients, since an attacker-controlled key/value blows up every span
+		// on the trace.
+		BaggageAllowList []string
 	}
 )
 
@@ -158,7 +184,7 @@ func TraceWithConfig(config TraceConfig) echo.MiddlewareFunc {
 			var sp opentracing.Span
 			var err error
 
-			ctx, err := config.Tracer.Extract(
+			spanCtx, err := config.Tracer.Extract(
 				opentracing.HTTPHeaders,
 				opentracing.HTTPHeadersCarrier(req.Header),
 			)
@@ -166,16 +192,62 @@ func TraceWithConfig(config TraceConfig) echo.MiddlewareFunc {
 			if err != nil {
 				sp = config.Tracer.StartSpan(opname)
 			} else {
-				sp = config.Tracer.StartSpan(opname, ext.RPCServerOption(ctx))
+				sp = config.Tracer.StartSpan(opname, ext.RPCServerOption(spanCtx))
 			}
 			defer sp.Finish()
 
+			if priority, ok := samplingPriority(config, c); ok {
+				ext.SamplingPriority.Set(sp, priority)
+			}
+
 			ext.HTTPMethod.Set(sp, req.Method)
 			ext.HTTPUrl.Set(sp, req.URL.String())
 			ext.Component.Set(sp, config.ComponentName)
 			sp.SetTag("client_ip", realIP)
 			sp.SetTag("request_id", requestID)
 
+			if config.BaggageExtractor != nil {
+				for k, v := range config.BaggageExtractor(c) {
+					sp.SetBaggageItem(k, v)
+				}
+			}
+
+			// Enforce BaggageAllowList against the span's full baggage set - both what
+			// BaggageExtractor just seeded above and whatever the Tracer.Extract call above
+			// carried over from an upstream service - so a client can't blow up every span on
+			// the trace by injecting baggage headers directly.
+			if len(config.BaggageAllowList) > 0 {
+				var disallowed []string
+				sp.Context().ForeachBaggageItem(func(k, v string) bool {
+					if !baggageKeyAllowed(config.BaggageAllowList, k) {
+						disallowed = append(disallowed, k)
+					}
+					return true
+				})
+				for _, k := range disallowed {
+					sp.SetBaggageItem(k, "")
+				}
+			}
+
+			// Attach the span to the request context before any of the body-dump/response-
+			// writer wiring below runs, so every downstream reader - including another copy
+			// of the request swapped in by later middleware - can reach it through
+			// SpanFromEchoContext/opentracing.SpanFromContext instead of a stale pointer
+			// captured earlier in the chain. See https://github.com/labstack/echo/issues/2413.
+			reqSpan := req.WithContext(opentracing.ContextWithSpan(req.Context(), sp))
+			c.SetRequest(reqSpan)
+			defer func() {
+				// As we created a new http.Request object, we need to make sure that
+				// temporary files created to hold MultipartForm files are cleaned up - this
+				// is normally done by http.Server, but it has no reference to our new Request
+				// instance. We re-read c.Request() here rather than closing over reqSpan
+				// because inner middleware may have replaced the request again by the time we
+				// get back here, and that is the instance whose MultipartForm is actually live.
+				if final := c.Request(); final != nil && final.MultipartForm != nil {
+					final.MultipartForm.RemoveAll()
+				}
+			}()
+
 			// Dump request & response body
 			var respDumper *responseDumper
 			if config.IsBodyDump {
@@ -191,28 +263,13 @@ func TraceWithConfig(config TraceConfig) echo.MiddlewareFunc {
 					}
 				}
 
-				req.Body = io.NopCloser(bytes.NewBuffer(reqBody)) // reset original request body
+				c.Request().Body = io.NopCloser(bytes.NewBuffer(reqBody)) // reset original request body
 
 				// response
 				respDumper = newResponseDumper(c.Response())
 				c.Response().Writer = respDumper
 			}
 
-			// setup request context - add opentracing span
-			reqSpan := req.WithContext(opentracing.ContextWithSpan(req.Context(), sp))
-			c.SetRequest(reqSpan)
-			defer func() {
-				// as we have created new http.Request object we need to make sure that temporary files created to hold MultipartForm
-				// files are cleaned up. This is done by http.Server at the end of request lifecycle but Server does not
-				// have reference to our new Request instance therefore it is our responsibility to fix the mess we caused.
-				//
-				// This means that when we are on returning path from handler middlewares up in chain from this middleware
-				// can not access these temporary files anymore because we deleted them here.
-				if reqSpan.MultipartForm != nil {
-					reqSpan.MultipartForm.RemoveAll()
-				}
-			}()
-
 			// call next middleware / controller
 			err = next(c)
 			if err != nil {
@@ -240,6 +297,46 @@ func TraceWithConfig(config TraceConfig) echo.MiddlewareFunc {
 	}
 }
 
+// samplingPriority resolves the effective jaeger sampling.priority tag for the request, if
+// any, by consulting SamplingDecisionFunc and falling back to RouteSampling. ok is false when
+// neither is configured (or RouteSampling has no entry for the route) and the Tracer's own
+// sampler should decide instead.
+func samplingPriority(config TraceConfig, c echo.Context) (priority uint16, ok bool) {
+	if config.SamplingDecisionFunc != nil {
+		sample, priority := config.SamplingDecisionFunc(c)
+		if !sample {
+			return 0, true
+		}
+		if priority == 0 {
+			priority = 1
+		}
+		return priority, true
+	}
+
+	if rate, ok := config.RouteSampling[c.Path()]; ok {
+		if mathrand.Float64() < rate {
+			return 1, true
+		}
+		return 0, true
+	}
+
+	return 0, false
+}
+
+// baggageKeyAllowed reports whether key may be seeded as baggage, per allowList. An empty
+// allowList allows every key.
+func baggageKeyAllowed(allowList []string, key string) bool {
+	if len(allowList) == 0 {
+		return true
+	}
+	for _, allowed := range allowList {
+		if allowed == key {
+			return true
+		}
+	}
+	return false
+}
+
 func limitString(str string, size int) string {
 	if len(str) > size {
 		return str[:size/2] + "\n---- skipped ----\n" + str[len(str)-size/2:]
@@ -277,12 +374,20 @@ func defaultOperationName(c echo.Context) string {
 	return "HTTP " + req.Method + " URL: " + c.Path()
 }
 
+// SpanFromEchoContext returns the span TraceWithConfig attached to c's request, regardless of
+// whether an inner middleware has since replaced c.Request() with another instance - callers
+// should use this instead of reaching into c.Request().Context() themselves. Returns nil if
+// no span is present (e.g. the route was skipped, or tracing middleware was never installed).
+func SpanFromEchoContext(c echo.Context) opentracing.Span {
+	return opentracing.SpanFromContext(c.Request().Context())
+}
+
 // TraceFunction wraps funtion with opentracing span adding tags for the function name and caller details
 func TraceFunction(ctx echo.Context, fn interface{}, params ...interface{}) (result []reflect.Value) {
 	// Get function name
 	name := runtime.FuncForPC(reflect.ValueOf(fn).Pointer()).Name()
 	// Create child span
-	parentSpan := opentracing.SpanFromContext(ctx.Request().Context())
+	parentSpan := SpanFromEchoContext(ctx)
 	sp := opentracing.StartSpan(
 		"Function - "+name,
 		opentracing.ChildOf(parentSpan.Context()))
@@ -314,7 +419,7 @@ func TraceFunction(ctx echo.Context, fn interface{}, params ...interface{}) (res
 // CreateChildSpan creates a new opentracing span adding tags for the span name and caller details.
 // User must call defer `sp.Finish()`
 func CreateChildSpan(ctx echo.Context, name string) opentracing.Span {
-	parentSpan := opentracing.SpanFromContext(ctx.Request().Context())
+	parentSpan := SpanFromEchoContext(ctx)
 	sp := opentracing.StartSpan(
 		name,
 		opentracing.ChildOf(parentSpan.Context()))
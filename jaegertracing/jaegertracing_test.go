@@ -0,0 +1,91 @@
+// SPDX-License-Identifier: MIT
+// SPDX-FileCopyrightText: © 2017 LabStack and Echo contributors
+
+package jaegertracing
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/mocktracer"
+)
+
+// TestCreateChildSpanAndTraceFunction_SurvivesRequestReplacement covers
+// https://github.com/labstack/echo/issues/2413: CreateChildSpan and TraceFunction must find
+// the span TraceWithConfig attached to the request, even after an inner middleware has
+// replaced c.Request() with another *http.Request instance.
+func TestCreateChildSpanAndTraceFunction_SurvivesRequestReplacement(t *testing.T) {
+	tracer := mocktracer.New()
+	// CreateChildSpan and TraceFunction start their spans on the global tracer.
+	prevTracer := opentracing.GlobalTracer()
+	opentracing.SetGlobalTracer(tracer)
+	defer opentracing.SetGlobalTracer(prevTracer)
+	e := echo.New()
+
+	var rootSpanID int
+	var sawChild, sawTraceFunction bool
+
+	mw := TraceWithConfig(TraceConfig{Tracer: tracer})
+	handler := mw(func(c echo.Context) error {
+		root := SpanFromEchoContext(c)
+		if root == nil {
+			t.Fatal("SpanFromEchoContext returned nil inside handler")
+		}
+		rootSpanID = root.Context().(mocktracer.MockSpanContext).SpanID
+
+		// Simulate an inner middleware swapping in a new *http.Request after tracing ran, as
+		// e.g. another body-dumping middleware would.
+		c.SetRequest(c.Request().Clone(c.Request().Context()))
+
+		child := CreateChildSpan(c, "child-after-swap")
+		if child.Context().(mocktracer.MockSpanContext).SpanID == rootSpanID {
+			t.Error("CreateChildSpan returned the root span instead of a new child")
+		}
+		child.Finish()
+		sawChild = true
+
+		TraceFunction(c, func() { sawTraceFunction = true })
+
+		return nil
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	if err := handler(c); err != nil {
+		t.Fatalf("handler returned error: %v", err)
+	}
+	if !sawChild || !sawTraceFunction {
+		t.Fatal("handler did not run to completion")
+	}
+
+	var childSpan *mocktracer.MockSpan
+	for _, sp := range tracer.FinishedSpans() {
+		if sp.OperationName == "child-after-swap" {
+			childSpan = sp
+		}
+	}
+	if childSpan == nil {
+		t.Fatal("child-after-swap span was never finished")
+	}
+	if childSpan.ParentID != rootSpanID {
+		t.Errorf("child span ParentID = %d, want %d (root span)", childSpan.ParentID, rootSpanID)
+	}
+}
+
+// TestSpanFromEchoContext_Nil ensures callers get a nil span (not a panic) when no tracing
+// middleware ran - e.g. because the route was skipped.
+func TestSpanFromEchoContext_Nil(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	if sp := SpanFromEchoContext(c); sp != nil {
+		t.Errorf("expected nil span, got %v", sp)
+	}
+}
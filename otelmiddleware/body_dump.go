@@ -0,0 +1,43 @@
+// SPDX-License-Identifier: MIT
+// SPDX-FileCopyrightText: © 2017 LabStack and Echo contributors
+
+package otelmiddleware
+
+import (
+	"bytes"
+	"crypto/rand"
+	"fmt"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+// responseDumper wraps echo.Response, tapping its body so it can be
+// reported on the span when IsBodyDump is enabled.
+type responseDumper struct {
+	http.ResponseWriter
+	buffer *bytes.Buffer
+}
+
+func newResponseDumper(resp *echo.Response) *responseDumper {
+	return &responseDumper{
+		ResponseWriter: resp.Writer,
+		buffer:         new(bytes.Buffer),
+	}
+}
+
+func (d *responseDumper) Write(b []byte) (int, error) {
+	d.buffer.Write(b)
+	return d.ResponseWriter.Write(b)
+}
+
+// GetResponse returns the buffered response body as a string.
+func (d *responseDumper) GetResponse() string {
+	return d.buffer.String()
+}
+
+func generateToken() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return fmt.Sprintf("%x", b)
+}
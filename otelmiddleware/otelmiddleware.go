@@ -0,0 +1,353 @@
+// SPDX-License-Identifier: MIT
+// SPDX-FileCopyrightText: © 2017 LabStack and Echo contributors
+
+/*
+Package otelmiddleware provides middleware to OpenTelemetry tracing.
+
+It mirrors the surface of the `jaegertracing` package but builds on
+`go.opentelemetry.io/otel` instead of OpenTracing/Jaeger, so it supports any
+OTel-compatible exporter (OTLP/gRPC, OTLP/HTTP, stdout, Jaeger via OTLP, ...).
+
+Example:
+```
+package main
+import (
+
+	"github.com/labstack/echo-contrib/otelmiddleware"
+	"github.com/labstack/echo/v4"
+	"go.opentelemetry.io/otel"
+
+)
+
+	func main() {
+	    e := echo.New()
+	    // The global provider is used here for brevity; normally tp is a
+	    // *sdktrace.TracerProvider created by the caller, per service, as
+	    // recommended by OpenTelemetry (see NewTracerProvider).
+	    tp := otel.GetTracerProvider()
+	    otelmiddleware.New(e, tp)
+
+	    e.Logger.Fatal(e.Start(":1323"))
+	}
+
+```
+*/
+package otelmiddleware
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"runtime"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const defaultComponentName = "echo/v4"
+
+type (
+	// TraceConfig defines the config for Trace middleware.
+	TraceConfig struct {
+		// Skipper defines a function to skip middleware.
+		Skipper middleware.Skipper
+
+		// TracerProvider is the OTel TracerProvider used to start spans.
+		// Callers are expected to build and own this themselves (one per
+		// service), including wiring up whichever exporter they want.
+		TracerProvider trace.TracerProvider
+
+		// Propagator extracts/injects the span context from/into request
+		// headers. Defaults to a composite of W3C tracecontext and baggage.
+		Propagator propagation.TextMapPropagator
+
+		// ComponentName used for describing the tracing component name
+		ComponentName string
+
+		// add req body & resp body to tracing tags
+		IsBodyDump bool
+
+		// prevent logging long http request bodies
+		LimitHTTPBody bool
+
+		// http body limit size (in bytes)
+		LimitSize int
+
+		// OperationNameFunc composes operation name based on context. Can be used to override default naming
+		OperationNameFunc func(c echo.Context) string
+	}
+)
+
+var (
+	// DefaultTraceConfig is the default Trace middleware config.
+	DefaultTraceConfig = TraceConfig{
+		Skipper:       middleware.DefaultSkipper,
+		ComponentName: defaultComponentName,
+		IsBodyDump:    false,
+
+		LimitHTTPBody:     true,
+		LimitSize:         60_000,
+		OperationNameFunc: defaultOperationName,
+		Propagator:        propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}),
+	}
+)
+
+// New creates the Trace middleware, using tp as the TracerProvider, and attaches it to e.
+//
+// Unlike jaegertracing.New, it does not create the TracerProvider itself:
+// OpenTelemetry recommends constructing one TracerProvider per service (wired
+// to whichever exporter you want, see NewTracerProvider together with
+// NewOTLPGRPCExporter, NewOTLPHTTPExporter, NewStdoutExporter and
+// NewJaegerExporter) and passing it in here.
+func New(e *echo.Echo, tp trace.TracerProvider) {
+	e.Use(TraceWithConfig(TraceConfig{TracerProvider: tp}))
+}
+
+// Trace returns a Trace middleware.
+// Trace middleware traces http requests and reporting errors.
+func Trace(tp trace.TracerProvider) echo.MiddlewareFunc {
+	c := DefaultTraceConfig
+	c.TracerProvider = tp
+	return TraceWithConfig(c)
+}
+
+// TraceWithConfig returns a Trace middleware with config.
+// See: `Trace()`.
+func TraceWithConfig(config TraceConfig) echo.MiddlewareFunc {
+	if config.TracerProvider == nil {
+		panic("echo: trace middleware requires an otel TracerProvider")
+	}
+	if config.Skipper == nil {
+		config.Skipper = middleware.DefaultSkipper
+	}
+	if config.ComponentName == "" {
+		config.ComponentName = defaultComponentName
+	}
+	if config.OperationNameFunc == nil {
+		config.OperationNameFunc = defaultOperationName
+	}
+	if config.Propagator == nil {
+		config.Propagator = propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{})
+	}
+
+	tracer := config.TracerProvider.Tracer(defaultComponentName)
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if config.Skipper(c) {
+				return next(c)
+			}
+
+			req := c.Request()
+			opname := config.OperationNameFunc(c)
+			realIP := c.RealIP()
+			requestID := getRequestID(c) // request-id generated by reverse-proxy
+
+			ctx := config.Propagator.Extract(req.Context(), propagation.HeaderCarrier(req.Header))
+			// Carry the configured TracerProvider alongside the span so CreateChildSpan and
+			// TraceFunction use the same per-service provider as this middleware, instead of
+			// falling back to the (likely no-op) global one.
+			ctx = contextWithTracerProvider(ctx, config.TracerProvider)
+			ctx, span := tracer.Start(ctx, opname, trace.WithSpanKind(trace.SpanKindServer))
+			defer span.End()
+
+			span.SetAttributes(
+				semconv.HTTPRequestMethodKey.String(req.Method),
+				semconv.URLFull(req.URL.String()),
+				attribute.String("component", config.ComponentName),
+				attribute.String("client_ip", realIP),
+				attribute.String("request_id", requestID),
+			)
+
+			// Dump request & response body
+			var respDumper *responseDumper
+			if config.IsBodyDump {
+				// request
+				reqBody := []byte{}
+				if c.Request().Body != nil {
+					reqBody, _ = io.ReadAll(c.Request().Body)
+
+					if config.LimitHTTPBody {
+						span.AddEvent("http.req.body", trace.WithAttributes(attribute.String("body", limitString(string(reqBody), config.LimitSize))))
+					} else {
+						span.AddEvent("http.req.body", trace.WithAttributes(attribute.String("body", string(reqBody))))
+					}
+				}
+
+				req.Body = io.NopCloser(bytes.NewBuffer(reqBody)) // reset original request body
+
+				// response
+				respDumper = newResponseDumper(c.Response())
+				c.Response().Writer = respDumper
+			}
+
+			// setup request context - add otel span
+			reqSpan := req.WithContext(ctx)
+			c.SetRequest(reqSpan)
+			defer func() {
+				// As we created a new http.Request object, we need to make sure that
+				// temporary files created to hold MultipartForm files are cleaned up - this
+				// is normally done by http.Server, but it has no reference to our new Request
+				// instance. We re-read c.Request() here rather than closing over reqSpan
+				// because inner middleware may have replaced the request again by the time we
+				// get back here, and that is the instance whose MultipartForm is actually
+				// live. See https://github.com/labstack/echo/issues/2413.
+				if final := c.Request(); final != nil && final.MultipartForm != nil {
+					final.MultipartForm.RemoveAll()
+				}
+			}()
+
+			// call next middleware / controller
+			err := next(c)
+			if err != nil {
+				c.Error(err) // call custom registered error handler
+			}
+
+			status := c.Response().Status
+			span.SetAttributes(semconv.HTTPResponseStatusCodeKey.Int(status))
+
+			if err != nil {
+				logError(span, err)
+			}
+
+			// Dump response body
+			if config.IsBodyDump {
+				if config.LimitHTTPBody {
+					span.AddEvent("http.resp.body", trace.WithAttributes(attribute.String("body", limitString(respDumper.GetResponse(), config.LimitSize))))
+				} else {
+					span.AddEvent("http.resp.body", trace.WithAttributes(attribute.String("body", respDumper.GetResponse())))
+				}
+			}
+
+			return nil // error was already processed with ctx.Error(err)
+		}
+	}
+}
+
+func limitString(str string, size int) string {
+	if len(str) > size {
+		return str[:size/2] + "\n---- skipped ----\n" + str[len(str)-size/2:]
+	}
+
+	return str
+}
+
+func logError(span trace.Span, err error) {
+	var httpError *echo.HTTPError
+	if errors.As(err, &httpError) {
+		span.AddEvent("error.message", trace.WithAttributes(attribute.String("message", fmt.Sprint(httpError.Message))))
+	} else {
+		span.AddEvent("error.message", trace.WithAttributes(attribute.String("message", err.Error())))
+	}
+	span.SetStatus(codes.Error, err.Error())
+	span.RecordError(err)
+}
+
+func getRequestID(ctx echo.Context) string {
+	requestID := ctx.Request().Header.Get(echo.HeaderXRequestID) // request-id generated by reverse-proxy
+	if requestID == "" {
+		requestID = generateToken() // missed request-id from proxy, we generate it manually
+	}
+	return requestID
+}
+
+// tracerProviderContextKey is the context key TraceWithConfig uses to carry its configured
+// TracerProvider so CreateChildSpan/TraceFunction can start children on the same provider.
+type tracerProviderContextKey struct{}
+
+func contextWithTracerProvider(ctx context.Context, tp trace.TracerProvider) context.Context {
+	return context.WithValue(ctx, tracerProviderContextKey{}, tp)
+}
+
+// tracerProviderFromContext returns the TracerProvider TraceWithConfig placed on ctx, falling
+// back to the OTel global provider if tracing middleware never ran on this request.
+func tracerProviderFromContext(ctx context.Context) trace.TracerProvider {
+	if tp, ok := ctx.Value(tracerProviderContextKey{}).(trace.TracerProvider); ok && tp != nil {
+		return tp
+	}
+	return otel.GetTracerProvider()
+}
+
+func defaultOperationName(c echo.Context) string {
+	req := c.Request()
+	return "HTTP " + req.Method + " URL: " + c.Path()
+}
+
+// TraceFunction wraps funtion with an otel span adding attributes for the function name and caller details
+func TraceFunction(ctx echo.Context, fn interface{}, params ...interface{}) (result []reflect.Value) {
+	// Get function name
+	name := runtime.FuncForPC(reflect.ValueOf(fn).Pointer()).Name()
+	// Create child span
+	sp := CreateChildSpan(ctx, "Function - "+name)
+	defer sp.End()
+
+	sp.SetAttributes(attribute.String("function", name))
+
+	// Get caller function name, file and line - CreateChildSpan recorded TraceFunction
+	// itself as the caller, so overwrite it with TraceFunction's own caller
+	pc := make([]uintptr, 15)
+	n := runtime.Callers(2, pc)
+	frames := runtime.CallersFrames(pc[:n])
+	frame, _ := frames.Next()
+	sp.SetAttributes(attribute.String("caller", fmt.Sprintf("%s - %s#%d", frame.Function, frame.File, frame.Line)))
+
+	// Check params and call function
+	f := reflect.ValueOf(fn)
+	if f.Type().NumIn() != len(params) {
+		e := fmt.Sprintf("Incorrect number of parameters calling wrapped function %s", name)
+		panic(e)
+	}
+	inputs := make([]reflect.Value, len(params))
+	for k, in := range params {
+		inputs[k] = reflect.ValueOf(in)
+	}
+	return f.Call(inputs)
+}
+
+// CreateChildSpan creates a new otel span adding attributes for the span name and caller details.
+// User must call defer `sp.End()`
+func CreateChildSpan(ctx echo.Context, name string) trace.Span {
+	reqCtx := ctx.Request().Context()
+	tracer := tracerProviderFromContext(reqCtx).Tracer(defaultComponentName)
+	_, sp := tracer.Start(reqCtx, name)
+	sp.SetAttributes(attribute.String("name", name))
+
+	// Get caller function name, file and line
+	pc := make([]uintptr, 15)
+	n := runtime.Callers(2, pc)
+	frames := runtime.CallersFrames(pc[:n])
+	frame, _ := frames.Next()
+	callerDetails := fmt.Sprintf("%s - %s#%d", frame.Function, frame.File, frame.Line)
+	sp.SetAttributes(attribute.String("caller", callerDetails))
+
+	return sp
+}
+
+// NewTracedRequest generates a new traced HTTP request with the current span context injected into it
+func NewTracedRequest(method string, url string, body io.Reader, span trace.Span) (*http.Request, error) {
+	req, err := http.NewRequest(method, url, body)
+	if err != nil {
+		panic(err.Error())
+	}
+
+	span.SetAttributes(
+		attribute.String("span.kind", "client"),
+		semconv.URLFull(url),
+		semconv.HTTPRequestMethodKey.String(method),
+	)
+	ctx := trace.ContextWithSpan(req.Context(), span)
+	propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}).
+		Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	return req, nil
+}
@@ -0,0 +1,68 @@
+// SPDX-License-Identifier: MIT
+// SPDX-FileCopyrightText: © 2017 LabStack and Echo contributors
+
+package otelmiddleware
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+)
+
+// NewTracerProvider builds a *sdktrace.TracerProvider for serviceName that
+// batches spans to exporter. It is a thin convenience wrapper: callers who
+// need more control (samplers, extra resource attributes, span processors)
+// should build their own sdktrace.TracerProvider instead - this package
+// never requires that you use it, only that you hand it a trace.TracerProvider.
+func NewTracerProvider(serviceName string, exporter sdktrace.SpanExporter) (*sdktrace.TracerProvider, error) {
+	res, err := resource.Merge(
+		resource.Default(),
+		resource.NewSchemaless(semconv.ServiceName(serviceName)),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	), nil
+}
+
+// NewOTLPGRPCExporter returns a span exporter that ships spans to an OTLP/gRPC
+// collector (e.g. the OpenTelemetry Collector, or Jaeger's OTLP receiver) at
+// endpoint, such as "localhost:4317".
+func NewOTLPGRPCExporter(ctx context.Context, endpoint string) (sdktrace.SpanExporter, error) {
+	return otlptracegrpc.New(ctx,
+		otlptracegrpc.WithEndpoint(endpoint),
+		otlptracegrpc.WithInsecure(),
+	)
+}
+
+// NewOTLPHTTPExporter returns a span exporter that ships spans to an OTLP/HTTP
+// collector at endpoint, such as "localhost:4318".
+func NewOTLPHTTPExporter(ctx context.Context, endpoint string) (sdktrace.SpanExporter, error) {
+	return otlptracehttp.New(ctx,
+		otlptracehttp.WithEndpoint(endpoint),
+		otlptracehttp.WithInsecure(),
+	)
+}
+
+// NewStdoutExporter returns a span exporter that pretty-prints spans to
+// stdout, useful for local development.
+func NewStdoutExporter() (sdktrace.SpanExporter, error) {
+	return stdouttrace.New(stdouttrace.WithPrettyPrint())
+}
+
+// NewJaegerExporter returns a span exporter that ships spans to a Jaeger
+// instance via its OTLP/gRPC receiver (e.g. "localhost:4317"). Jaeger has
+// deprecated its native Thrift collector endpoint in favor of OTLP, so this
+// is just NewOTLPGRPCExporter under another name for discoverability.
+func NewJaegerExporter(ctx context.Context, endpoint string) (sdktrace.SpanExporter, error) {
+	return NewOTLPGRPCExporter(ctx, endpoint)
+}